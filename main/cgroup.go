@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+)
+
+// cgroupSliceDir is where cgroup v2 scopes for run-command executions are
+// created when systemd-run isn't available to do it for us.
+const cgroupSliceDir = "/sys/fs/cgroup/runcommand.slice"
+
+// cgroupFallbackExecutor applies cfg's resource limits by writing cgroup v2
+// controller files directly, for hosts without systemd. It is not a
+// sandbox: the command still runs as a normal child of this process.
+type cgroupFallbackExecutor struct{}
+
+func (cgroupFallbackExecutor) Run(ctx *log.Context, cmd, workdir string, stdout, stderr io.WriteCloser, cfg *handlerSettings) (int, error) {
+	cgroupDir := filepath.Join(cgroupSliceDir, strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := os.MkdirAll(cgroupDir, 0700); err != nil {
+		return 0, errors.Wrapf(err, "failed to create cgroup directory: %s", cgroupDir)
+	}
+	defer os.Remove(cgroupDir)
+
+	if err := writeCgroupLimits(cgroupDir, cfg); err != nil {
+		return 0, err
+	}
+
+	command := exec.Command("/bin/bash", "-c", cmd)
+	if err := applyRunAsUser(command, cfg); err != nil {
+		return 0, err
+	}
+	command.Dir = workdir
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	if err := startManagedCommand(command); err != nil {
+		return 0, errors.Wrap(err, "failed to start command")
+	}
+
+	if err := writeCgroupFile(cgroupDir, "cgroup.procs", strconv.Itoa(command.Process.Pid)); err != nil {
+		ctx.Log("message", "failed to attach command to cgroup: "+err.Error())
+	}
+
+	return waitManagedCommand(ctx, command, cfg)
+}
+
+// writeCgroupLimits writes the memory.max/cpu.max/pids.max controller files
+// for the limits configured in cfg. Unset limits are left at the cgroup's
+// default (unlimited).
+func writeCgroupLimits(cgroupDir string, cfg *handlerSettings) error {
+	if cfg.publicSettings.MemoryLimitMB > 0 {
+		limit := fmt.Sprintf("%d", cfg.publicSettings.MemoryLimitMB*1024*1024)
+		if err := writeCgroupFile(cgroupDir, "memory.max", limit); err != nil {
+			return err
+		}
+	}
+	if cfg.publicSettings.CPUQuotaPercent > 0 {
+		// cpu.max is "$MAX $PERIOD" in microseconds; a 100ms period keeps
+		// the math simple (quota = period * percent / 100).
+		const periodUs = 100000
+		quota := periodUs * cfg.publicSettings.CPUQuotaPercent / 100
+		if err := writeCgroupFile(cgroupDir, "cpu.max", fmt.Sprintf("%d %d", quota, periodUs)); err != nil {
+			return err
+		}
+	}
+	if cfg.publicSettings.PidsMax > 0 {
+		if err := writeCgroupFile(cgroupDir, "pids.max", strconv.Itoa(cfg.publicSettings.PidsMax)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCgroupFile(cgroupDir, name, value string) error {
+	path := filepath.Join(cgroupDir, name)
+	return errors.Wrapf(os.WriteFile(path, []byte(value), 0600), "failed to write %s", path)
+}
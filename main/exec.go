@@ -1,80 +1,36 @@
 package main
 
 import (
-	"context"
-	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"os/user"
 	"path/filepath"
-	"strconv"
-	"syscall"
-	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 )
 
-// Exec runs the given cmd in /bin/sh, saves its stdout/stderr streams to
-// the specified files. It waits until the execution terminates.
+// Exec runs cmd under whichever Executor selectExecutor picks for cfg
+// (plain /bin/bash, a resource-limited systemd scope, or process
+// replacement via syscall.Exec), saving its stdout/stderr streams to the
+// specified files. When cfg.protectedSettings.Std{out,err}AppendBlobURL is
+// set, the corresponding stream is also mirrored to that append blob as it
+// is produced. It waits until the execution terminates.
 //
 // On error, an exit code may be returned if it is an exit code error.
 // Given stdout and stderr will be closed upon returning.
 func Exec(ctx *log.Context, cmd, workdir string, stdout, stderr io.WriteCloser, cfg *handlerSettings) (int, error) {
+	stdout = withAppendBlobStreaming(ctx, stdout, cfg.protectedSettings.StdoutAppendBlobURL)
+	stderr = withAppendBlobStreaming(ctx, stderr, cfg.protectedSettings.StderrAppendBlobURL)
 	defer stdout.Close()
 	defer stderr.Close()
 
 	commandArgs, err := SetEnvironmentVariables(cfg)
 	cmd = cmd + commandArgs // Add command args if any
-
-	//executionMessage := ""   // TODO: return
-	exitCode := 0 // TODO: return exit code and execution state
-	var command *exec.Cmd
-	if cfg.publicSettings.TimeoutInSeconds > 0 {
-		commandContext, cancel := context.WithTimeout(context.Background(), time.Duration(1)*time.Second)
-		defer cancel()
-		command = exec.CommandContext(commandContext, "/bin/bash", "-c", cmd)
-		ctx.Log("message", "Execute with TimeoutInSeconds="+strconv.Itoa(cfg.publicSettings.TimeoutInSeconds))
-	} else {
-		command = exec.Command("/bin/bash", "-c", cmd)
-	}
-
-	// If RunAsUser is set by customer we need to execute the script under that user
-	// Password is not needed because extension process runs under root and has permission to execute under different user
-	if cfg.publicSettings.RunAsUser != "" {
-		ctx.Log("message", "RunAsUser="+cfg.publicSettings.RunAsUser)
-		runAsUser, err := user.Lookup(cfg.publicSettings.RunAsUser)
-		if err != nil {
-			return exitCode, err
-		}
-
-		uid, _ := strconv.Atoi(runAsUser.Uid)
-		gid, _ := strconv.Atoi(runAsUser.Gid)
-
-		command.SysProcAttr = &syscall.SysProcAttr{}
-		command.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid),
-			Gid: uint32(gid)}
-	}
-
-	command.Dir = workdir
-	command.Stdout = stdout
-	command.Stderr = stderr
-	err = command.Run()
 	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok {
-			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-				exitCode = status.ExitStatus()
-				if status.Signaled() { // Timed out
-					ctx.Log("message", "Timeout:"+err.Error())
-				}
-				return exitCode, fmt.Errorf("command terminated with exit status=%d", exitCode)
-			}
-		}
+		return 0, err
 	}
 
-	return exitCode, errors.Wrapf(err, "failed to execute command")
+	return selectExecutor(cfg).Run(ctx, cmd, workdir, stdout, stderr, cfg)
 }
 
 func SetEnvironmentVariables(cfg *handlerSettings) (string, error) {
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+)
+
+// Executor runs a shell command, writing its output to stdout/stderr and
+// returning its exit code. Implementations differ in how (and whether) they
+// isolate and resource-limit the command.
+type Executor interface {
+	Run(ctx *log.Context, cmd, workdir string, stdout, stderr io.WriteCloser, cfg *handlerSettings) (int, error)
+}
+
+// selectExecutor picks the Executor for cfg: an explicit publicSettings.ExecutorType
+// choice takes precedence; otherwise a resource limit implies the systemd
+// scope executor, and a plain /bin/bash runner is used by default.
+func selectExecutor(cfg *handlerSettings) Executor {
+	switch cfg.publicSettings.ExecutorType {
+	case "exec":
+		return syscallExecExecutor{}
+	case "systemd-scope":
+		return systemdScopeExecutor{}
+	case "bash":
+		return bashExecutor{}
+	case "":
+		if cfg.publicSettings.MemoryLimitMB > 0 || cfg.publicSettings.CPUQuotaPercent > 0 || cfg.publicSettings.PidsMax > 0 {
+			return systemdScopeExecutor{}
+		}
+		return bashExecutor{}
+	default:
+		return bashExecutor{}
+	}
+}
+
+// ErrTimedOut is returned when the command did not finish within
+// publicSettings.TimeoutInSeconds and had to be terminated.
+var ErrTimedOut = errors.New("command timed out")
+
+// terminationGracePeriod is how long a timed-out process group is given to
+// exit after SIGTERM before it is sent SIGKILL.
+const terminationGracePeriod = 5 * time.Second
+
+// bashExecutor runs the command as a plain child of the extension process,
+// via /bin/bash -c. This is the long-standing default behavior.
+type bashExecutor struct{}
+
+func (bashExecutor) Run(ctx *log.Context, cmd, workdir string, stdout, stderr io.WriteCloser, cfg *handlerSettings) (int, error) {
+	command := exec.Command("/bin/bash", "-c", cmd)
+
+	if err := applyRunAsUser(command, cfg); err != nil {
+		return 0, err
+	}
+
+	command.Dir = workdir
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	if err := startManagedCommand(command); err != nil {
+		return 0, errors.Wrap(err, "failed to start command")
+	}
+
+	return waitManagedCommand(ctx, command, cfg)
+}
+
+// startManagedCommand starts command in its own process group without
+// disturbing any SysProcAttr fields already set on it (e.g. by
+// applyRunAsUser), so waitManagedCommand can terminate the whole group if
+// the command times out. Every Executor that runs the script as a direct
+// child process goes through this, not just bashExecutor.
+func startManagedCommand(command *exec.Cmd) error {
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Setpgid = true
+	return command.Start()
+}
+
+// waitManagedCommand waits for a command started by startManagedCommand to
+// finish, enforcing cfg.publicSettings.TimeoutInSeconds (if positive) by
+// terminating its whole process group on expiry.
+func waitManagedCommand(ctx *log.Context, command *exec.Cmd, cfg *handlerSettings) (int, error) {
+	if cfg.publicSettings.TimeoutInSeconds <= 0 {
+		return exitCodeOf(command.Wait())
+	}
+
+	timeout := time.Duration(cfg.publicSettings.TimeoutInSeconds) * time.Second
+	ctx.Log("message", "Execute with TimeoutInSeconds="+strconv.Itoa(cfg.publicSettings.TimeoutInSeconds))
+	return runWithTimeout(ctx, command, timeout)
+}
+
+// runWithTimeout waits for command to finish, and if it doesn't within
+// timeout, terminates its entire process group so no descendants are left
+// behind, returning ErrTimedOut.
+func runWithTimeout(ctx *log.Context, command *exec.Cmd, timeout time.Duration) (int, error) {
+	done := make(chan error, 1)
+	go func() { done <- command.Wait() }()
+
+	select {
+	case err := <-done:
+		return exitCodeOf(err)
+	case <-time.After(timeout):
+		ctx.Log("message", fmt.Sprintf("command timed out after %s, terminating process group pgid=%d", timeout, command.Process.Pid))
+		terminateProcessGroup(command.Process.Pid, done)
+		return 0, ErrTimedOut
+	}
+}
+
+// terminateProcessGroup sends SIGTERM to the process group led by pid, then
+// SIGKILL if it hasn't exited within terminationGracePeriod. done must be
+// the channel command.Wait()'s result is delivered on.
+func terminateProcessGroup(pid int, done <-chan error) {
+	pgid := -pid
+	syscall.Kill(pgid, syscall.SIGTERM)
+
+	select {
+	case <-done:
+		return
+	case <-time.After(terminationGracePeriod):
+		syscall.Kill(pgid, syscall.SIGKILL)
+		<-done
+	}
+}
+
+// applyRunAsUser configures command to run as cfg.publicSettings.RunAsUser,
+// if set. The extension process runs as root, so no password is needed to
+// switch to the target user.
+func applyRunAsUser(command *exec.Cmd, cfg *handlerSettings) error {
+	if cfg.publicSettings.RunAsUser == "" {
+		return nil
+	}
+
+	runAsUser, err := user.Lookup(cfg.publicSettings.RunAsUser)
+	if err != nil {
+		return err
+	}
+
+	uid, _ := strconv.Atoi(runAsUser.Uid)
+	gid, _ := strconv.Atoi(runAsUser.Gid)
+
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	return nil
+}
+
+// systemdScopeExecutor runs the command inside a transient systemd scope
+// (systemd-run --scope), which lets it enforce memory/CPU/pids limits via
+// cgroups without the extension managing the cgroup hierarchy itself. It
+// falls back to cgroupFallbackExecutor when systemd-run isn't available.
+type systemdScopeExecutor struct{}
+
+func (e systemdScopeExecutor) Run(ctx *log.Context, cmd, workdir string, stdout, stderr io.WriteCloser, cfg *handlerSettings) (int, error) {
+	systemdRun, err := exec.LookPath("systemd-run")
+	if err != nil {
+		ctx.Log("message", "systemd-run not found, falling back to direct cgroup v2 management")
+		return cgroupFallbackExecutor{}.Run(ctx, cmd, workdir, stdout, stderr, cfg)
+	}
+
+	args := []string{"--scope", "--collect"}
+	for _, p := range scopeProperties(cfg) {
+		args = append(args, "-p", p)
+	}
+
+	if cfg.publicSettings.RunAsUser != "" {
+		runAsUser, err := user.Lookup(cfg.publicSettings.RunAsUser)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, "--uid="+runAsUser.Uid, "--gid="+runAsUser.Gid)
+	}
+
+	args = append(args, "--", "/bin/bash", "-c", cmd)
+
+	command := exec.Command(systemdRun, args...)
+	command.Dir = workdir
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	if err := startManagedCommand(command); err != nil {
+		return 0, errors.Wrap(err, "failed to start command")
+	}
+	return waitManagedCommand(ctx, command, cfg)
+}
+
+// scopeProperties translates cfg's resource-limit settings into systemd-run
+// `-p` unit properties.
+func scopeProperties(cfg *handlerSettings) []string {
+	var props []string
+	if cfg.publicSettings.MemoryLimitMB > 0 {
+		props = append(props, fmt.Sprintf("MemoryMax=%dM", cfg.publicSettings.MemoryLimitMB))
+	}
+	if cfg.publicSettings.CPUQuotaPercent > 0 {
+		props = append(props, fmt.Sprintf("CPUQuota=%d%%", cfg.publicSettings.CPUQuotaPercent))
+	}
+	if cfg.publicSettings.PidsMax > 0 {
+		props = append(props, fmt.Sprintf("TasksMax=%d", cfg.publicSettings.PidsMax))
+	}
+	return props
+}
+
+// syscallExecExecutor replaces the current process image with the target
+// command via syscall.Exec. Unlike the other executors it never returns on
+// success: the calling process becomes the script. It exists for callers
+// that invoke the extension as a short-lived, one-shot wrapper and don't
+// need to observe the exit code afterward.
+type syscallExecExecutor struct{}
+
+func (syscallExecExecutor) Run(ctx *log.Context, cmd, workdir string, stdout, stderr io.WriteCloser, cfg *handlerSettings) (int, error) {
+	outFile, ok := stdout.(*os.File)
+	if !ok {
+		return 0, fmt.Errorf("exec executor requires file-backed stdout")
+	}
+	errFile, ok := stderr.(*os.File)
+	if !ok {
+		return 0, fmt.Errorf("exec executor requires file-backed stderr")
+	}
+
+	if err := os.Chdir(workdir); err != nil {
+		return 0, err
+	}
+	if err := syscall.Dup2(int(outFile.Fd()), int(os.Stdout.Fd())); err != nil {
+		return 0, err
+	}
+	if err := syscall.Dup2(int(errFile.Fd()), int(os.Stderr.Fd())); err != nil {
+		return 0, err
+	}
+
+	bash, err := exec.LookPath("/bin/bash")
+	if err != nil {
+		return 0, err
+	}
+
+	ctx.Log("message", "replacing process image via syscall.Exec")
+	err = syscall.Exec(bash, []string{"bash", "-c", cmd}, os.Environ())
+	// syscall.Exec only returns on error; success replaces this process.
+	return 0, err
+}
+
+// exitCodeOf extracts the child's exit code from the error returned by
+// exec.Cmd.Run, matching the convention used throughout this package.
+func exitCodeOf(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), fmt.Errorf("command terminated with exit status=%d", status.ExitStatus())
+		}
+	}
+	return 0, err
+}
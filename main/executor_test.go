@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+type nopWriteCloser struct{ bytes.Buffer }
+
+func (*nopWriteCloser) Close() error { return nil }
+
+func Test_bashExecutor_timeout_killsWholeProcessGroup(t *testing.T) {
+	cfg := &handlerSettings{publicSettings: publicSettings{TimeoutInSeconds: 1}}
+
+	var stdout, stderr nopWriteCloser
+	_, err := bashExecutor{}.Run(log.NewContext(log.NewNopLogger()), "sleep 30 & wait", "/tmp", &stdout, &stderr, cfg)
+	require.Equal(t, ErrTimedOut, err)
+
+	// Give the grace period a chance to elapse, then confirm no grandchild
+	// ("sleep 30", backgrounded by the shell) survived the kill.
+	time.Sleep(terminationGracePeriod + 2*time.Second)
+	out, _ := exec.Command("pgrep", "-f", "sleep 30").CombinedOutput()
+	require.Empty(t, strings.TrimSpace(string(out)), "expected no leftover descendants, pgrep found: %s", out)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/koralski/run-command-extension-linux/pkg/blobutil"
+	"github.com/koralski/run-command-extension-linux/pkg/download"
+	"github.com/pkg/errors"
+)
+
+// blobDownloaderFor picks the Downloader implementation for blob according
+// to whichever credentials protected supplies, preferring a pre-signed SAS
+// URL, then a storage account key, then the instance's managed identity.
+func blobDownloaderFor(protected protectedSettings, identity managedIdentitySettings, blob blobutil.AzureBlobRef) download.Downloader {
+	switch {
+	case protected.BlobSASURL != "":
+		return download.NewSASBlobDownload(protected.BlobSASURL)
+	case protected.StorageAccountKey != "":
+		return download.NewBlobDownload(protected.StorageAccountName, protected.StorageAccountKey, blob)
+	default:
+		return download.NewManagedIdentityBlobDownload(blob, identity.ClientID)
+	}
+}
+
+// getDownloaders inspects fullURL and returns the ordered list of
+// download.Downloader implementations able to fetch it, trying an Azure
+// blob, Amazon S3 or Google Cloud Storage downloader for the matching
+// URI schemes/hostnames and falling back to a generic URL downloader
+// otherwise. Azure blob sources are routed through blobDownloaderFor,
+// which picks the SAS/key/managed-identity authenticator according to
+// whichever credentials identity/protected supply.
+func getDownloaders(fullURL string, protected protectedSettings, identity managedIdentitySettings) ([]download.Downloader, error) {
+	switch {
+	case strings.HasPrefix(fullURL, "s3://"):
+		bucket, key, err := parseS3URI(fullURL)
+		if err != nil {
+			return nil, err
+		}
+		return []download.Downloader{download.NewS3Download(bucket, key, protected.AWSCredentials)}, nil
+	case strings.HasPrefix(fullURL, "gs://"):
+		bucket, object, err := parseGCSURI(fullURL)
+		if err != nil {
+			return nil, err
+		}
+		return []download.Downloader{download.NewGCSDownload(bucket, object, protected.GCPServiceAccountJSON)}, nil
+	default:
+		if blob, ok := parseAzureBlobURL(fullURL); ok {
+			return []download.Downloader{blobDownloaderFor(protected, identity, blob)}, nil
+		}
+		if bucket, key, ok := parseVirtualHostedS3URL(fullURL); ok {
+			return []download.Downloader{download.NewS3Download(bucket, key, protected.AWSCredentials)}, nil
+		}
+		return []download.Downloader{download.NewURLDownload(fullURL)}, nil
+	}
+}
+
+// parseAzureBlobURL recognizes Azure Blob Storage URLs of the form
+// "https://account.blob.<storageBase>/container/blob", as produced by the
+// Azure portal and CLI, and splits out the blobutil.AzureBlobRef they
+// address. It reports ok=false for anything else, including a bare blob
+// service root with no container/blob path, which is left to the generic
+// URL downloader.
+func parseAzureBlobURL(rawURL string) (blobutil.AzureBlobRef, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return blobutil.AzureBlobRef{}, false
+	}
+
+	hostParts := strings.Split(u.Host, ".")
+	if len(hostParts) < 3 || hostParts[1] != "blob" {
+		return blobutil.AzureBlobRef{}, false
+	}
+
+	segments := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+		return blobutil.AzureBlobRef{}, false
+	}
+
+	return blobutil.AzureBlobRef{
+		Account:     hostParts[0],
+		StorageBase: strings.Join(hostParts[2:], "."),
+		Container:   segments[0],
+		Blob:        segments[1],
+	}, true
+}
+
+// parseS3URI parses an "s3://bucket/key" URI into its bucket and key parts.
+func parseS3URI(uri string) (bucket, key string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "unable to parse S3 URI: %q", uri)
+	}
+	bucket = u.Host
+	key = strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("S3 URI %q must be of the form s3://bucket/key", uri)
+	}
+	return bucket, key, nil
+}
+
+// parseGCSURI parses a "gs://bucket/object" URI into its bucket and object parts.
+func parseGCSURI(uri string) (bucket, object string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "unable to parse GCS URI: %q", uri)
+	}
+	bucket = u.Host
+	object = strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || object == "" {
+		return "", "", fmt.Errorf("GCS URI %q must be of the form gs://bucket/object", uri)
+	}
+	return bucket, object, nil
+}
+
+// parseVirtualHostedS3URL recognizes virtual-hosted-style S3 URLs such as
+// "https://bucket.s3.amazonaws.com/key" or
+// "https://bucket.s3.us-west-2.amazonaws.com/key".
+func parseVirtualHostedS3URL(rawURL string) (bucket, key string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", false
+	}
+	hostParts := strings.Split(u.Host, ".")
+	if len(hostParts) < 3 {
+		return "", "", false
+	}
+	for i, part := range hostParts {
+		if part == "s3" && i > 0 && hostParts[len(hostParts)-1] == "com" {
+			return strings.Join(hostParts[:i], "."), strings.TrimPrefix(u.Path, "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// urlToFileName derives a destination file name from the last path segment
+// of rawURL. A URL ending in "/" (or with no path at all) has no file name
+// to extract and is rejected, rather than falling back to some parent
+// segment.
+func urlToFileName(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to parse URL: %q", rawURL)
+	}
+
+	segments := strings.Split(u.Path, "/")
+	last := segments[len(segments)-1]
+	if last == "" {
+		return "", fmt.Errorf("cannot extract file name from URL: %q", rawURL)
+	}
+	return last, nil
+}
+
+// postProcessFile strips Windows-style line endings from a downloaded script
+// and makes it executable by the owner only.
+func postProcessFile(filePath string) error {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read file: %s", filePath)
+	}
+
+	b = []byte(strings.Replace(string(b), "\r\n", "\n", -1))
+
+	if err := ioutil.WriteFile(filePath, b, 0500); err != nil {
+		return errors.Wrapf(err, "failed to write file: %s", filePath)
+	}
+	return os.Chmod(filePath, 0500)
+}
+
+// saveScriptFile writes an inline script to filePath.
+func saveScriptFile(filePath, content string) error {
+	return errors.Wrapf(ioutil.WriteFile(filePath, []byte(content), 0500), "failed to save script file: %s", filePath)
+}
+
+// downloadAndProcessURL downloads fullURL into dir, naming the destination
+// file after the URL's last path segment, and post-processes it so it is
+// ready to execute.
+func downloadAndProcessURL(ctx *log.Context, fullURL, dir string, cfg *handlerSettings) (string, error) {
+	fileName, err := urlToFileName(fullURL)
+	if err != nil {
+		return "", err
+	}
+
+	downloaders, err := getDownloaders(fullURL, cfg.protectedSettings, cfg.publicSettings.ManagedIdentity)
+	if err != nil {
+		return "", err
+	}
+
+	filePath := path.Join(dir, fileName)
+	chunkOpts := download.ChunkOptions{
+		ChunkSize: int64(cfg.publicSettings.DownloadChunkSizeMB) * 1024 * 1024,
+		Workers:   cfg.publicSettings.DownloadParallelism,
+	}
+	checksumOpts := download.ChecksumOptions{
+		ExpectedMD5:    cfg.publicSettings.ExpectedMD5,
+		ExpectedSHA256: cfg.publicSettings.ExpectedSHA256,
+	}
+
+	var lastErr error
+	for _, d := range downloaders {
+		if err := download.DownloadToFile(d, filePath, chunkOpts, checksumOpts); err != nil {
+			lastErr = err
+			continue
+		}
+		return filePath, postProcessFile(filePath)
+	}
+	return "", errors.Wrap(lastErr, "all downloaders failed")
+}
@@ -14,39 +14,75 @@ import (
 )
 
 func Test_getDownloader_azureBlob(t *testing.T) {
-	// // error condition
-	// _, err := getDownloaders("http://acct.blob.core.windows.net/", "acct", "key")
-	// require.NotNil(t, err)
-
-	// // valid input
-	// d, err := getDownloaders("http://acct.blob.core.windows.net/container/blob", "acct", "key")
-	// require.Nil(t, err)
-	// require.NotNil(t, d)
-	// require.Equal(t, 1, len(d))
-	// require.Equal(t, "download.blobDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
+	// Defaults to managed-identity auth when no SAS URL or storage account
+	// key is configured.
+	d, err := getDownloaders("https://acct.blob.core.windows.net/container/blob", protectedSettings{}, managedIdentitySettings{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(d))
+	require.Equal(t, "download.managedIdentityBlobDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
+
+	// A configured storage account key takes precedence.
+	d, err = getDownloaders("https://acct.blob.core.windows.net/container/blob", protectedSettings{
+		StorageAccountName: "acct",
+		StorageAccountKey:  "Zm9vCg==",
+	}, managedIdentitySettings{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(d))
+	require.Equal(t, "download.blobDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
+
+	// A configured SAS URL takes precedence over everything else.
+	d, err = getDownloaders("https://acct.blob.core.windows.net/container/blob", protectedSettings{
+		BlobSASURL: "https://acct.blob.core.windows.net/container/blob?sig=abc",
+	}, managedIdentitySettings{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(d))
+	require.Equal(t, "download.blobSASURLDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
 }
 
 func Test_getDownloader_externalUrl(t *testing.T) {
-	d, err := getDownloaders("http://acct.blob.core.windows.net/")
+	// A blob service root with no container/blob path isn't a recognizable
+	// blob reference, so it falls back to the generic URL downloader.
+	d, err := getDownloaders("http://acct.blob.core.windows.net/", protectedSettings{}, managedIdentitySettings{})
 	require.Nil(t, err)
 	require.NotNil(t, d)
 	require.NotEmpty(t, d)
 	require.Equal(t, 1, len(d))
 	require.Equal(t, "download.urlDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
 
-	d, err = getDownloaders("http://acct.blob.core.windows.net/")
+	d, err = getDownloaders("http://acct.blob.core.windows.net/", protectedSettings{}, managedIdentitySettings{})
 	require.Nil(t, err)
 	require.NotNil(t, d)
 	require.Equal(t, 1, len(d))
 	require.Equal(t, "download.urlDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
 
-	d, err = getDownloaders("http://acct.blob.core.windows.net/")
+	d, err = getDownloaders("http://acct.blob.core.windows.net/", protectedSettings{}, managedIdentitySettings{})
 	require.Nil(t, err)
 	require.NotNil(t, d)
 	require.Equal(t, 1, len(d))
 	require.Equal(t, "download.urlDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
 }
 
+func Test_getDownloader_s3URI(t *testing.T) {
+	d, err := getDownloaders("s3://my-bucket/scripts/install.sh", protectedSettings{}, managedIdentitySettings{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(d))
+	require.Equal(t, "download.s3Download", fmt.Sprintf("%T", d[0]), "got wrong type")
+}
+
+func Test_getDownloader_s3VirtualHosted(t *testing.T) {
+	d, err := getDownloaders("https://my-bucket.s3.us-west-2.amazonaws.com/scripts/install.sh", protectedSettings{}, managedIdentitySettings{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(d))
+	require.Equal(t, "download.s3Download", fmt.Sprintf("%T", d[0]), "got wrong type")
+}
+
+func Test_getDownloader_gcsURI(t *testing.T) {
+	d, err := getDownloaders("gs://my-bucket/scripts/install.sh", protectedSettings{}, managedIdentitySettings{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(d))
+	require.Equal(t, "download.gcsDownload", fmt.Sprintf("%T", d[0]), "got wrong type")
+}
+
 func Test_urlToFileName_badURL(t *testing.T) {
 	_, err := urlToFileName("http://192.168.0.%31/")
 	require.NotNil(t, err)
@@ -0,0 +1,106 @@
+package main
+
+import "github.com/koralski/run-command-extension-linux/pkg/download"
+
+// parameterDefinition is a single named or positional parameter passed to
+// the run command script. Named parameters (Name set) are exported as
+// environment variables; unnamed ones are appended as command arguments.
+type parameterDefinition struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// scriptSource describes where the script to execute comes from: either
+// inline (Script) or fetched from a URL/blob (ScriptURI).
+type scriptSource struct {
+	Script    string `json:"script"`
+	ScriptURI string `json:"scriptUri"`
+}
+
+// publicSettings holds the customer-visible extension configuration.
+type publicSettings struct {
+	Source           scriptSource          `json:"source"`
+	Parameters       []parameterDefinition `json:"parameters"`
+	RunAsUser        string                `json:"runAsUser"`
+	TimeoutInSeconds int                   `json:"timeoutInSeconds"`
+
+	// ManagedIdentity selects the managed identity that authenticates blob
+	// downloads when protectedSettings has neither a SAS URL nor a storage
+	// account key; either of those takes precedence over it.
+	ManagedIdentity managedIdentitySettings `json:"managedIdentity"`
+
+	// DownloadChunkSizeMB and DownloadParallelism tune the ranged,
+	// concurrent download used for sources that support HTTP Range
+	// requests. Zero values fall back to download.DefaultChunkSize/
+	// download.DefaultWorkers.
+	DownloadChunkSizeMB int `json:"downloadChunkSizeMB"`
+	DownloadParallelism int `json:"downloadParallelism"`
+
+	// ExecutorType picks how the script is run: "bash" (default) runs it
+	// as a plain child process, "systemd-scope" runs it in a resource-limited
+	// transient systemd scope, and "exec" replaces the extension process
+	// with it via syscall.Exec. Leaving this unset and setting any of
+	// MemoryLimitMB/CPUQuotaPercent/PidsMax implies "systemd-scope".
+	ExecutorType string `json:"executorType"`
+
+	// MemoryLimitMB, CPUQuotaPercent and PidsMax cap the script's resource
+	// usage when run under the systemd-scope executor, via MemoryMax,
+	// CPUQuota and TasksMax unit properties respectively. Zero means no
+	// limit.
+	MemoryLimitMB   int `json:"memoryLimitMB"`
+	CPUQuotaPercent int `json:"cpuQuotaPercent"`
+	PidsMax         int `json:"pidsMax"`
+
+	// ExpectedMD5 and ExpectedSHA256, when set, are compared against the
+	// downloaded script's computed hash; a mismatch fails the download and
+	// removes the partially or fully written file. ExpectedMD5 is
+	// base64-encoded, matching Content-MD5; ExpectedSHA256 is hex-encoded.
+	ExpectedMD5    string `json:"expectedMD5"`
+	ExpectedSHA256 string `json:"expectedSHA256"`
+}
+
+// protectedSettings holds the encrypted counterpart of publicSettings, used
+// for values that must not be logged or stored in plain text (credentials,
+// protected parameters).
+type protectedSettings struct {
+	ProtectedParameters []parameterDefinition `json:"protectedParameters"`
+	StorageAccountName  string                `json:"storageAccountName"`
+	StorageAccountKey   string                `json:"storageAccountKey"`
+
+	// BlobSASURL, when set, is used as-is to fetch the blob instead of
+	// signing one from StorageAccountName/StorageAccountKey.
+	BlobSASURL string `json:"blobSasUrl"`
+
+	// AWSCredentials authenticates requests to Amazon S3 when the script
+	// source is an s3:// URI or a virtual-hosted-style S3 URL. Left zero
+	// valued, IMDS-based credential resolution on the instance is used
+	// instead.
+	AWSCredentials download.S3Credentials `json:"awsCredentials"`
+
+	// GCPServiceAccountJSON is the raw contents of a GCP service account
+	// key file, used to authenticate requests to Google Cloud Storage
+	// when the script source is a gs:// URI.
+	GCPServiceAccountJSON string `json:"gcpServiceAccountJson"`
+
+	// StdoutAppendBlobURL and StderrAppendBlobURL, when set, stream the
+	// script's output to the given append blob (URL including a SAS query
+	// string with write permission) in near-real-time, in addition to the
+	// local stdout/stderr files.
+	StdoutAppendBlobURL string `json:"stdoutAppendBlobUrl"`
+	StderrAppendBlobURL string `json:"stderrAppendBlobUrl"`
+}
+
+// managedIdentitySettings selects the managed identity used to fetch a blob
+// when neither a storage account key nor a SAS URL is supplied. An empty
+// ClientID means the VM's system-assigned identity.
+type managedIdentitySettings struct {
+	ClientID string `json:"clientId"`
+	ObjectID string `json:"objectId"`
+}
+
+// handlerSettings is the parsed, merged view of the extension's public and
+// protected settings for a single sequence number.
+type handlerSettings struct {
+	publicSettings
+	protectedSettings
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/koralski/run-command-extension-linux/pkg/appendblob"
+)
+
+// appendBlobFlushInterval bounds how long output can sit unflushed in an
+// append blob stream when the script isn't producing newlines.
+const appendBlobFlushInterval = 5 * time.Second
+
+// withAppendBlobStreaming wraps local so writes also go to an append blob
+// stream when appendBlobURL is set, letting callers tail output (e.g. via
+// Azure Portal / Log Analytics) while the script is still running. Errors
+// from the append blob side are logged and swallowed rather than
+// propagated: os/exec aborts its io.Copy on the first Write error, so
+// letting a transient streaming failure through would silently truncate
+// the local stdout/stderr files too, which this feature must not affect.
+func withAppendBlobStreaming(ctx *log.Context, local io.WriteCloser, appendBlobURL string) io.WriteCloser {
+	if appendBlobURL == "" {
+		return local
+	}
+	remote := appendblob.NewWriter(appendBlobURL, appendBlobFlushInterval)
+	return multiWriteCloser{local, bestEffortWriteCloser{ctx: ctx, w: remote}}
+}
+
+// bestEffortWriteCloser logs and swallows errors from the wrapped writer
+// instead of returning them, so it can be fanned out to alongside an
+// authoritative sink without a failure in it aborting the whole pipeline.
+type bestEffortWriteCloser struct {
+	ctx *log.Context
+	w   io.WriteCloser
+}
+
+func (b bestEffortWriteCloser) Write(p []byte) (int, error) {
+	if _, err := b.w.Write(p); err != nil {
+		b.ctx.Log("message", "failed to stream output to append blob: "+err.Error())
+	}
+	return len(p), nil
+}
+
+func (b bestEffortWriteCloser) Close() error {
+	if err := b.w.Close(); err != nil {
+		b.ctx.Log("message", "failed to close append blob stream: "+err.Error())
+	}
+	return nil
+}
+
+// multiWriteCloser fans out writes to every member writer and closes them
+// all, returning the first error encountered from either operation.
+type multiWriteCloser []io.WriteCloser
+
+func (m multiWriteCloser) Write(p []byte) (int, error) {
+	for _, w := range m {
+		if n, err := w.Write(p); err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
+
+func (m multiWriteCloser) Close() error {
+	var firstErr error
+	for _, w := range m {
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,163 @@
+// Package appendblob streams writes to an Azure Storage append blob via the
+// AppendBlock REST API, so callers can tail output while it is still being
+// produced rather than waiting for the writer to close.
+package appendblob
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// appendBlockAPIVersion is the Blob Storage REST API version this package
+// was written against.
+const appendBlockAPIVersion = "2019-12-12"
+
+const (
+	maxAppendAttempts = 5
+	initialBackoff    = 500 * time.Millisecond
+)
+
+// Writer buffers writes and flushes them to an append blob on newline
+// boundaries or, failing that, at least once per FlushInterval. It
+// implements io.WriteCloser.
+type Writer struct {
+	// BlobURL is the append blob's URL, including a SAS query string with
+	// write permission.
+	BlobURL string
+	// FlushInterval is the maximum time data can sit in the buffer before
+	// being flushed, even without a newline.
+	FlushInterval time.Duration
+
+	client *http.Client
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+
+	stopTicker chan struct{}
+	tickerDone chan struct{}
+}
+
+// NewWriter returns a Writer that appends to blobURL, flushing on newline
+// boundaries or every flushInterval, whichever comes first.
+func NewWriter(blobURL string, flushInterval time.Duration) *Writer {
+	w := &Writer{
+		BlobURL:       blobURL,
+		FlushInterval: flushInterval,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		stopTicker:    make(chan struct{}),
+		tickerDone:    make(chan struct{}),
+	}
+	go w.flushPeriodically()
+	return w
+}
+
+func (w *Writer) flushPeriodically() {
+	defer close(w.tickerDone)
+	ticker := time.NewTicker(w.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mu.Lock()
+			w.flushLocked()
+			w.mu.Unlock()
+		case <-w.stopTicker:
+			return
+		}
+	}
+}
+
+// Write buffers p, flushing immediately if it contains a newline.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.buf.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if bytes.ContainsRune(p, '\n') {
+		if err := w.flushLocked(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Close flushes any buffered data and stops the periodic flush goroutine.
+func (w *Writer) Close() error {
+	close(w.stopTicker)
+	<-w.tickerDone
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flushLocked()
+}
+
+// flushLocked sends the buffered contents as a single AppendBlock request.
+// Callers must hold w.mu.
+func (w *Writer) flushLocked() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	data := append([]byte(nil), w.buf.Bytes()...)
+	w.buf.Reset()
+	return w.appendBlock(data)
+}
+
+// appendBlock performs the AppendBlock REST call, retrying with exponential
+// backoff on server errors and on a concurrent-append conflict
+// (AppendPositionConditionNotMet).
+func (w *Writer) appendBlock(data []byte) error {
+	backoff := initialBackoff
+	var lastErr error
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest("PUT", appendBlockURL(w.BlobURL), bytes.NewReader(data))
+		if err != nil {
+			return errors.Wrap(err, "failed to build AppendBlock request")
+		}
+		req.Header.Set("x-ms-version", appendBlockAPIVersion)
+		req.ContentLength = int64(len(data))
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			lastErr = errors.Wrap(err, "AppendBlock request failed")
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusCreated {
+			return nil
+		}
+
+		retryable := resp.StatusCode/100 == 5 || resp.Header.Get("x-ms-error-code") == "AppendPositionConditionNotMet"
+		lastErr = fmt.Errorf("AppendBlock failed: status=%d code=%s", resp.StatusCode, resp.Header.Get("x-ms-error-code"))
+		if !retryable {
+			return lastErr
+		}
+	}
+	return errors.Wrap(lastErr, "AppendBlock retries exhausted")
+}
+
+// appendBlockURL appends the comp=appendblock query parameter to a blob URL
+// that may already carry a SAS query string.
+func appendBlockURL(blobURL string) string {
+	sep := "?"
+	for _, r := range blobURL {
+		if r == '?' {
+			sep = "&"
+			break
+		}
+	}
+	return blobURL + sep + "comp=appendblock"
+}
@@ -0,0 +1,14 @@
+// Package blobutil contains small, dependency-free helpers for referring to
+// Azure Storage blobs from handler settings.
+package blobutil
+
+// AzureBlobRef uniquely identifies a blob within an Azure Storage account.
+// StorageBase is the storage service DNS suffix (e.g. "core.windows.net"),
+// allowing the same type to address public cloud and sovereign/ASE
+// environments alike.
+type AzureBlobRef struct {
+	Account     string `json:"account"`
+	StorageBase string `json:"storageBase"`
+	Container   string `json:"container"`
+	Blob        string `json:"blob"`
+}
@@ -0,0 +1,61 @@
+package download
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/storage"
+	"github.com/koralski/run-command-extension-linux/pkg/blobutil"
+	"github.com/pkg/errors"
+)
+
+// sasExpiry is how long the generated SAS URL remains valid for. The
+// download happens immediately after generation, so this only needs to
+// cover clock skew and retry attempts.
+const sasExpiry = 30 * time.Minute
+
+// blobDownload fetches a blob from an Azure Storage account, authenticating
+// with the storage account's shared key and downloading over a
+// self-generated read-only SAS URL.
+type blobDownload struct {
+	account string
+	key     string
+	blob    blobutil.AzureBlobRef
+}
+
+// NewBlobDownload returns a Downloader that fetches blob from the given
+// Azure Storage account using the account's shared key.
+func NewBlobDownload(account, key string, blob blobutil.AzureBlobRef) Downloader {
+	return blobDownload{account: account, key: key, blob: blob}
+}
+
+func (d blobDownload) GetRequest() (*http.Request, error) {
+	url, err := d.getURL()
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest("GET", url, nil)
+}
+
+// getURL generates a read-only SAS URL for the configured blob.
+func (d blobDownload) getURL() (string, error) {
+	client, err := storage.NewClient(d.account, d.key, d.blob.StorageBase, storage.DefaultAPIVersion, true)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to initialize azure storage client")
+	}
+
+	container := d.blob.Container
+	if container == "" {
+		container = "$root"
+	}
+
+	blobStorageClient := client.GetBlobService()
+	blobRef := blobStorageClient.GetContainerReference(container).GetBlobReference(d.blob.Blob)
+	return blobRef.GetSASURI(storage.BlobSASOptions{
+		BlobServiceSASPermissions: storage.BlobServiceSASPermissions{Read: true},
+		SASOptions: storage.SASOptions{
+			Start:  time.Now(),
+			Expiry: time.Now().Add(sasExpiry),
+		},
+	})
+}
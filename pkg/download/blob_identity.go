@@ -0,0 +1,80 @@
+package download
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/koralski/run-command-extension-linux/pkg/blobutil"
+	"github.com/pkg/errors"
+)
+
+// azureStorageResource is the AAD resource identifier used to request a
+// token scoped to Azure Storage.
+const azureStorageResource = "https://storage.azure.com/"
+
+// azureStorageAPIVersion is the Blob Storage REST API version required when
+// authenticating with an AAD bearer token instead of a SAS/shared key.
+const azureStorageAPIVersion = "2020-04-08"
+
+// rfc1123Now formats the current time as required by the x-ms-date header.
+func rfc1123Now() string {
+	return time.Now().UTC().Format(http.TimeFormat)
+}
+
+// blobSASURLDownload fetches a blob from a caller-supplied, already-signed
+// SAS URL. No further authentication is needed.
+type blobSASURLDownload string
+
+// NewSASBlobDownload returns a Downloader that fetches the blob addressed by
+// sasURL, which must already carry a valid SAS query string.
+func NewSASBlobDownload(sasURL string) Downloader {
+	return blobSASURLDownload(sasURL)
+}
+
+func (d blobSASURLDownload) GetRequest() (*http.Request, error) {
+	return http.NewRequest("GET", string(d), nil)
+}
+
+// managedIdentityBlobDownload fetches a blob using an AAD token obtained
+// through the instance metadata service, rather than a storage account key
+// or SAS token. ClientID selects a user-assigned managed identity; left
+// empty, the VM's system-assigned identity is used.
+type managedIdentityBlobDownload struct {
+	blob     blobutil.AzureBlobRef
+	clientID string
+}
+
+// NewManagedIdentityBlobDownload returns a Downloader that fetches blob
+// using the VM's managed identity instead of a storage account key. Pass an
+// empty clientID to use the system-assigned identity, or the client ID of a
+// user-assigned identity otherwise.
+func NewManagedIdentityBlobDownload(blob blobutil.AzureBlobRef, clientID string) Downloader {
+	return managedIdentityBlobDownload{blob: blob, clientID: clientID}
+}
+
+func (d managedIdentityBlobDownload) GetRequest() (*http.Request, error) {
+	token, err := getIMDSToken(azureStorageResource, d.clientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to acquire managed identity token")
+	}
+
+	req, err := http.NewRequest("GET", blobURL(d.blob), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("x-ms-version", azureStorageAPIVersion)
+	req.Header.Set("x-ms-date", rfc1123Now())
+	return req, nil
+}
+
+// blobURL builds blob's plain (unsigned) HTTPS address, e.g.
+// "https://account.blob.core.windows.net/container/blob".
+func blobURL(blob blobutil.AzureBlobRef) string {
+	container := blob.Container
+	if container == "" {
+		container = "$root"
+	}
+	return "https://" + blob.Account + ".blob." + blob.StorageBase + "/" + container + "/" + blob.Blob
+}
@@ -0,0 +1,52 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/koralski/run-command-extension-linux/pkg/blobutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_blobURL(t *testing.T) {
+	url := blobURL(blobutil.AzureBlobRef{
+		Account:     "account",
+		StorageBase: "test.core.windows.net",
+		Container:   "mycontainer",
+		Blob:        "blob.txt",
+	})
+	require.Equal(t, "https://account.blob.test.core.windows.net/mycontainer/blob.txt", url)
+}
+
+func Test_blobURL_defaultContainer(t *testing.T) {
+	url := blobURL(blobutil.AzureBlobRef{
+		Account:     "account",
+		StorageBase: "test.core.windows.net",
+		Blob:        "blob.txt",
+	})
+	require.Contains(t, url, "/$root/blob.txt")
+}
+
+func Test_managedIdentityBlobDownload_getRequest_failsWithoutIMDS(t *testing.T) {
+	d := NewManagedIdentityBlobDownload(blobutil.AzureBlobRef{
+		Account:     "account",
+		StorageBase: "test.core.windows.net",
+		Container:   "mycontainer",
+		Blob:        "blob.txt",
+	}, "")
+
+	// Without a real instance metadata service to answer the token request,
+	// GetRequest fails at the IMDS call, before the blob URL it would have
+	// built is ever used; blobURL's own tests above cover that part.
+	_, err := d.GetRequest()
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "failed to acquire managed identity token")
+}
+
+func Test_blobSASURLDownload_getRequest(t *testing.T) {
+	d := NewSASBlobDownload("https://account.blob.core.windows.net/container/blob.txt?sig=abc&se=123")
+
+	req, err := d.GetRequest()
+	require.Nil(t, err)
+	require.Equal(t, "GET", req.Method)
+	require.Equal(t, "https://account.blob.core.windows.net/container/blob.txt?sig=abc&se=123", req.URL.String())
+}
@@ -0,0 +1,81 @@
+package download
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// newHashes returns a fresh MD5 and SHA-256 hasher pair, for use together in
+// an io.MultiWriter so a single pass over the data computes both.
+func newHashes() (md5Sum, sha256Sum hash.Hash) {
+	return md5.New(), sha256.New()
+}
+
+// ErrChecksumMismatch is returned when a downloaded file's computed hash
+// doesn't match the declared value or the value the storage service
+// reported for it. The destination file is removed before this is returned.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumOptions declares the expected content hashes for a download.
+// ExpectedMD5 is base64-encoded, matching the Content-MD5 header
+// convention; ExpectedSHA256 is hex-encoded. Either, or both, may be left
+// empty to skip that comparison.
+type ChecksumOptions struct {
+	ExpectedMD5    string
+	ExpectedSHA256 string
+}
+
+func (o ChecksumOptions) enabled() bool {
+	return o.ExpectedMD5 != "" || o.ExpectedSHA256 != ""
+}
+
+// verifyFile re-reads path, computing its MD5 and SHA-256, and checks them
+// against opts and against reportedMD5 (the Content-MD5 or
+// x-ms-blob-content-md5 header the source returned, if any). The file is
+// removed if verification is requested and fails.
+func verifyFile(path string, opts ChecksumOptions, reportedMD5 string) error {
+	if !opts.enabled() && reportedMD5 == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open downloaded file for checksum verification")
+	}
+	md5Sum, sha256Sum := newHashes()
+	_, err = io.Copy(io.MultiWriter(md5Sum, sha256Sum), f)
+	f.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to read downloaded file for checksum verification")
+	}
+
+	if err := compareChecksums(md5Sum, sha256Sum, opts, reportedMD5); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+func compareChecksums(md5Sum, sha256Sum hash.Hash, opts ChecksumOptions, reportedMD5 string) error {
+	actualMD5 := base64.StdEncoding.EncodeToString(md5Sum.Sum(nil))
+	actualSHA256 := hex.EncodeToString(sha256Sum.Sum(nil))
+
+	if opts.ExpectedMD5 != "" && opts.ExpectedMD5 != actualMD5 {
+		return errors.Wrapf(ErrChecksumMismatch, "MD5: expected=%s actual=%s", opts.ExpectedMD5, actualMD5)
+	}
+	if opts.ExpectedSHA256 != "" && !strings.EqualFold(opts.ExpectedSHA256, actualSHA256) {
+		return errors.Wrapf(ErrChecksumMismatch, "SHA-256: expected=%s actual=%s", opts.ExpectedSHA256, actualSHA256)
+	}
+	if reportedMD5 != "" && reportedMD5 != actualMD5 {
+		return errors.Wrapf(ErrChecksumMismatch, "Content-MD5 reported by source: expected=%s actual=%s", reportedMD5, actualMD5)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package download
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTempFile(t *testing.T, content []byte) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "")
+	require.Nil(t, err)
+	_, err = f.Write(content)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+	return f.Name()
+}
+
+func Test_verifyFile_noExpectations_isNoop(t *testing.T) {
+	path := writeTempFile(t, []byte("hello"))
+	defer os.Remove(path)
+
+	require.Nil(t, verifyFile(path, ChecksumOptions{}, ""))
+
+	_, err := os.Stat(path)
+	require.Nil(t, err, "file should not be touched when no checksum is expected")
+}
+
+func Test_verifyFile_md5Match(t *testing.T) {
+	content := []byte("hello, world")
+	path := writeTempFile(t, content)
+	defer os.Remove(path)
+
+	sum := md5.Sum(content)
+	err := verifyFile(path, ChecksumOptions{ExpectedMD5: base64.StdEncoding.EncodeToString(sum[:])}, "")
+	require.Nil(t, err)
+
+	_, statErr := os.Stat(path)
+	require.Nil(t, statErr)
+}
+
+func Test_verifyFile_sha256Match_caseInsensitive(t *testing.T) {
+	content := []byte("hello, world")
+	path := writeTempFile(t, content)
+	defer os.Remove(path)
+
+	sum := sha256.Sum256(content)
+	err := verifyFile(path, ChecksumOptions{ExpectedSHA256: strings.ToUpper(hex.EncodeToString(sum[:]))}, "")
+	require.Nil(t, err)
+}
+
+func Test_verifyFile_md5Mismatch_removesFile(t *testing.T) {
+	path := writeTempFile(t, []byte("hello, world"))
+
+	err := verifyFile(path, ChecksumOptions{ExpectedMD5: "not-the-right-hash"}, "")
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+
+	_, statErr := os.Stat(path)
+	require.True(t, os.IsNotExist(statErr), "mismatched file should have been removed")
+}
+
+func Test_verifyFile_sha256Mismatch_removesFile(t *testing.T) {
+	path := writeTempFile(t, []byte("hello, world"))
+
+	err := verifyFile(path, ChecksumOptions{ExpectedSHA256: "not-the-right-hash"}, "")
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+
+	_, statErr := os.Stat(path)
+	require.True(t, os.IsNotExist(statErr))
+}
+
+func Test_verifyFile_reportedMD5Mismatch_removesFile(t *testing.T) {
+	path := writeTempFile(t, []byte("hello, world"))
+
+	// No explicit ChecksumOptions expectation, but the source's own
+	// Content-MD5/x-ms-blob-content-md5 header disagrees with what was
+	// actually written to disk.
+	err := verifyFile(path, ChecksumOptions{}, "not-the-right-hash")
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+
+	_, statErr := os.Stat(path)
+	require.True(t, os.IsNotExist(statErr))
+}
@@ -0,0 +1,120 @@
+package download
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rangeableTestServer serves payload at every path via http.ServeContent, which
+// honors Range requests the same way Azure Blob Storage and S3 do, so it
+// exercises DownloadToFile's chunked path.
+func rangeableTestServer(t *testing.T, payload []byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Unix(0, 0), bytes.NewReader(payload))
+	}))
+}
+
+func Test_DownloadToFile_chunked(t *testing.T) {
+	payload := make([]byte, 5*37+11) // not a multiple of the chunk size below
+	rand.New(rand.NewSource(1)).Read(payload)
+
+	srv := rangeableTestServer(t, payload)
+	defer srv.Close()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	destPath := filepath.Join(tmpDir, "downloaded")
+	err = DownloadToFile(NewURLDownload(srv.URL), destPath, ChunkOptions{ChunkSize: 37, Workers: 3}, ChecksumOptions{})
+	require.Nil(t, err)
+
+	got, err := ioutil.ReadFile(destPath)
+	require.Nil(t, err)
+	require.Equal(t, payload, got)
+}
+
+func Test_DownloadToFile_singleStream_whenNotRangeable(t *testing.T) {
+	payload := []byte("no ranges here, just one plain response body")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ignores any Range header and always returns the full body with 200,
+		// as a plain static file server with Range support disabled would.
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	destPath := filepath.Join(tmpDir, "downloaded")
+	err = DownloadToFile(NewURLDownload(srv.URL), destPath, ChunkOptions{}, ChecksumOptions{})
+	require.Nil(t, err)
+
+	got, err := ioutil.ReadFile(destPath)
+	require.Nil(t, err)
+	require.Equal(t, payload, got)
+}
+
+func Test_DownloadToFile_chunked_checksumMismatch_removesFile(t *testing.T) {
+	payload := make([]byte, 256)
+	rand.New(rand.NewSource(2)).Read(payload)
+
+	srv := rangeableTestServer(t, payload)
+	defer srv.Close()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	destPath := filepath.Join(tmpDir, "downloaded")
+	err = DownloadToFile(NewURLDownload(srv.URL), destPath, ChunkOptions{ChunkSize: 64, Workers: 2}, ChecksumOptions{
+		ExpectedSHA256: "0000000000000000000000000000000000000000000000000000000000000000",
+	})
+	require.NotNil(t, err)
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+
+	_, statErr := os.Stat(destPath)
+	require.True(t, os.IsNotExist(statErr), "expected downloaded file to be removed after checksum mismatch")
+}
+
+func Test_DownloadToFile_chunked_checksumMatch(t *testing.T) {
+	payload := make([]byte, 256)
+	rand.New(rand.NewSource(3)).Read(payload)
+	md5Sum := md5.Sum(payload)
+	sha256Sum := sha256.Sum256(payload)
+
+	srv := rangeableTestServer(t, payload)
+	defer srv.Close()
+
+	tmpDir, err := ioutil.TempDir("", "")
+	require.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	destPath := filepath.Join(tmpDir, "downloaded")
+	err = DownloadToFile(NewURLDownload(srv.URL), destPath, ChunkOptions{ChunkSize: 64, Workers: 2}, ChecksumOptions{
+		ExpectedMD5:    base64.StdEncoding.EncodeToString(md5Sum[:]),
+		ExpectedSHA256: hex.EncodeToString(sha256Sum[:]),
+	})
+	require.Nil(t, err)
+
+	got, err := ioutil.ReadFile(destPath)
+	require.Nil(t, err)
+	require.Equal(t, payload, got)
+}
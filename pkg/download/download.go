@@ -0,0 +1,41 @@
+// Package download provides pluggable Downloader implementations used by the
+// run-command extension to fetch a script or artifact before execution.
+package download
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Downloader builds the outgoing HTTP request needed to retrieve a payload.
+// Implementations encapsulate whatever addressing/authentication a given
+// source requires (a plain URL, an Azure Storage blob SAS, etc.).
+type Downloader interface {
+	GetRequest() (*http.Request, error)
+}
+
+// Download executes the request produced by d and returns the response
+// status code together with the response body on success. The caller is
+// responsible for closing the returned body. A non-2xx status code is
+// treated as an error.
+func Download(d Downloader) (int, io.ReadCloser, error) {
+	req, err := d.GetRequest()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "http request failed")
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return resp.StatusCode, nil, fmt.Errorf("unexpected status code: actual=%d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, resp.Body, nil
+}
@@ -0,0 +1,62 @@
+package download
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/oauth2/google"
+)
+
+// gcsDownloadScope is the minimal OAuth scope needed to read objects.
+const gcsDownloadScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// gcsDownload fetches an object from Google Cloud Storage, authenticating
+// with a service account if one is configured, or the instance's attached
+// service account otherwise.
+type gcsDownload struct {
+	bucket, object     string
+	serviceAccountJSON string
+}
+
+// NewGCSDownload returns a Downloader that fetches object from bucket in
+// Google Cloud Storage. If serviceAccountJSON is empty, credentials are
+// instead resolved from the GCE instance's attached service account.
+func NewGCSDownload(bucket, object, serviceAccountJSON string) Downloader {
+	return gcsDownload{bucket: bucket, object: object, serviceAccountJSON: serviceAccountJSON}
+}
+
+func (d gcsDownload) GetRequest() (*http.Request, error) {
+	req, err := http.NewRequest("GET", "https://storage.googleapis.com/"+d.bucket+"/"+d.object, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := d.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// accessToken resolves an OAuth2 access token for the configured
+// credentials, mirroring how Download uses the returned request as-is.
+func (d gcsDownload) accessToken() (string, error) {
+	ctx := context.Background()
+	var creds *google.Credentials
+	var err error
+	if d.serviceAccountJSON != "" {
+		creds, err = google.CredentialsFromJSON(ctx, []byte(d.serviceAccountJSON), gcsDownloadScope)
+	} else {
+		creds, err = google.FindDefaultCredentials(ctx, gcsDownloadScope)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
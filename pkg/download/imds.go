@@ -0,0 +1,117 @@
+package download
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// imdsTokenEndpoint is Azure Instance Metadata Service's token endpoint,
+// reachable only from inside the VM.
+const imdsTokenEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// imdsAPIVersion is the minimum IMDS API version that supports the
+// identity/oauth2/token endpoint.
+const imdsAPIVersion = "2018-02-01"
+
+// imdsTokenRefreshMargin is how long before a cached token's actual expiry
+// it is treated as stale and refetched, so a download in progress never
+// races the real expiry.
+const imdsTokenRefreshMargin = 5 * time.Minute
+
+var imdsClient = &http.Client{Timeout: 10 * time.Second}
+
+// imdsToken is the subset of IMDS's oauth2/token response this package uses.
+type imdsToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresOn   string `json:"expires_on"`
+}
+
+// cachedIMDSToken is an access token along with when it stops being usable.
+type cachedIMDSToken struct {
+	accessToken string
+	expiresOn   time.Time
+}
+
+var (
+	imdsTokenCacheMu sync.Mutex
+	imdsTokenCache   = map[string]cachedIMDSToken{}
+)
+
+// getIMDSToken acquires an AAD access token for resource from the instance
+// metadata service, using the VM's system-assigned managed identity, or the
+// user-assigned identity identified by clientID when non-empty. Tokens are
+// cached per resource/clientID and reused until they're within
+// imdsTokenRefreshMargin of expiring, so a parallel chunked download doesn't
+// hit IMDS (which throttles aggressively) once per worker per chunk.
+func getIMDSToken(resource, clientID string) (string, error) {
+	cacheKey := resource + "|" + clientID
+
+	imdsTokenCacheMu.Lock()
+	cached, ok := imdsTokenCache[cacheKey]
+	imdsTokenCacheMu.Unlock()
+	if ok && time.Now().Before(cached.expiresOn.Add(-imdsTokenRefreshMargin)) {
+		return cached.accessToken, nil
+	}
+
+	tok, err := fetchIMDSToken(resource, clientID)
+	if err != nil {
+		return "", err
+	}
+
+	if expiresOn, err := parseIMDSExpiry(tok.ExpiresOn); err == nil {
+		imdsTokenCacheMu.Lock()
+		imdsTokenCache[cacheKey] = cachedIMDSToken{accessToken: tok.AccessToken, expiresOn: expiresOn}
+		imdsTokenCacheMu.Unlock()
+	}
+
+	return tok.AccessToken, nil
+}
+
+// fetchIMDSToken unconditionally requests a fresh token from the instance
+// metadata service.
+func fetchIMDSToken(resource, clientID string) (imdsToken, error) {
+	q := url.Values{}
+	q.Set("api-version", imdsAPIVersion)
+	q.Set("resource", resource)
+	if clientID != "" {
+		q.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequest("GET", imdsTokenEndpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return imdsToken{}, errors.Wrap(err, "failed to build IMDS token request")
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := imdsClient.Do(req)
+	if err != nil {
+		return imdsToken{}, errors.Wrap(err, "failed to reach instance metadata service")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return imdsToken{}, errors.Errorf("instance metadata service returned status=%d", resp.StatusCode)
+	}
+
+	var tok imdsToken
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return imdsToken{}, errors.Wrap(err, "failed to decode instance metadata service response")
+	}
+	return tok, nil
+}
+
+// parseIMDSExpiry parses IMDS's expires_on field, a Unix timestamp encoded
+// as a decimal string.
+func parseIMDSExpiry(expiresOn string) (time.Time, error) {
+	sec, err := strconv.ParseInt(expiresOn, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to parse IMDS token expiry")
+	}
+	return time.Unix(sec, 0), nil
+}
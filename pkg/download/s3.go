@@ -0,0 +1,73 @@
+package download
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+)
+
+// S3Credentials authenticates requests to Amazon S3. A zero value means
+// "resolve credentials from the instance's IMDS role instead".
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+}
+
+const defaultS3Region = "us-east-1"
+
+// s3Download fetches an object from Amazon S3, signing the request with
+// SigV4 using either the supplied static credentials or the EC2 instance
+// role resolved via IMDS.
+type s3Download struct {
+	bucket, key string
+	creds       S3Credentials
+}
+
+// NewS3Download returns a Downloader that fetches key from bucket in Amazon
+// S3. If creds has no AccessKeyID, credentials are instead resolved from the
+// EC2 instance metadata service.
+func NewS3Download(bucket, key string, creds S3Credentials) Downloader {
+	return s3Download{bucket: bucket, key: key, creds: creds}
+}
+
+func (d s3Download) GetRequest() (*http.Request, error) {
+	region := d.creds.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	provider := d.credentialsProvider()
+	req, err := http.NewRequest("GET", s3.New(session.Must(session.NewSession())).Endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build S3 request")
+	}
+	req.URL.Scheme = "https"
+	req.URL.Host = d.bucket + ".s3." + region + ".amazonaws.com"
+	req.URL.Path = "/" + d.key
+
+	signer := v4.NewSigner(credentials.NewCredentials(provider))
+	if _, err := signer.Sign(req, nil, "s3", region, time.Now()); err != nil {
+		return nil, errors.Wrap(err, "failed to sign S3 request")
+	}
+	return req, nil
+}
+
+// credentialsProvider returns a static provider when an access key was
+// configured, falling back to the EC2 instance role otherwise.
+func (d s3Download) credentialsProvider() credentials.Provider {
+	if d.creds.AccessKeyID != "" {
+		return &credentials.StaticProvider{Value: credentials.Value{
+			AccessKeyID:     d.creds.AccessKeyID,
+			SecretAccessKey: d.creds.SecretAccessKey,
+		}}
+	}
+	return &ec2rolecreds.EC2RoleProvider{Client: ec2metadata.New(session.Must(session.NewSession()))}
+}
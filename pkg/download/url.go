@@ -0,0 +1,16 @@
+package download
+
+import "net/http"
+
+// urlDownload fetches a payload from an arbitrary, publicly reachable URL.
+// It requires no credentials of its own.
+type urlDownload string
+
+// NewURLDownload returns a Downloader that fetches the payload at url as-is.
+func NewURLDownload(url string) Downloader {
+	return urlDownload(url)
+}
+
+func (d urlDownload) GetRequest() (*http.Request, error) {
+	return http.NewRequest("GET", string(d), nil)
+}